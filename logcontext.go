@@ -0,0 +1,84 @@
+// mgo - MongoDB driver for Go
+//
+// Copyright (c) 2010-2012 - Gustavo Niemeyer <gustavo@niemeyer.net>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package mgo
+
+import "context"
+
+// This file holds the logging-side primitives that Session.WithLogger and
+// Query.WithContext (session_shim.go) are built on: ContextWithLogFields
+// attaches per-request fields to a context.Context, and LoggerFor resolves
+// a session's attached logger together with those fields for a given
+// (owner, ctx) pair.
+
+type logFieldsKey struct{}
+
+// ContextWithLogFields returns a copy of ctx carrying keysAndValues as
+// structured fields (trace id, deadline, database, collection, comment,
+// ...) to be attached to every log line emitted while that context is live,
+// so wire-level debug output from a specific query can be correlated with
+// the caller's own request traces.
+func ContextWithLogFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	if existing, ok := ctx.Value(logFieldsKey{}).([]interface{}); ok {
+		keysAndValues = append(append([]interface{}{}, existing...), keysAndValues...)
+	}
+	return context.WithValue(ctx, logFieldsKey{}, keysAndValues)
+}
+
+// structuredLoggerProvider is implemented by an owner (e.g. Session) that
+// can report its own attached StructuredLogger. LoggerFor uses it in place
+// of a package-level registry, so a logger's lifetime is exactly that of
+// the struct field already set by WithLogger -- nothing to leak, nothing to
+// evict, and nothing keyed on the owner's identity for the package to hold
+// onto after the owner itself is gone.
+type structuredLoggerProvider interface {
+	attachedLogger() StructuredLogger
+}
+
+// LoggerFor resolves the StructuredLogger that should be used for a log
+// line produced on behalf of owner (typically a *Session) while executing
+// within ctx: it starts from owner's own attached logger if owner
+// implements structuredLoggerProvider (falling back to the package-wide
+// logger from getStructuredLogger), then decorates it with any fields
+// attached to ctx by ContextWithLogFields.
+func LoggerFor(owner interface{}, ctx context.Context) StructuredLogger {
+	logger := getStructuredLogger()
+	if provider, ok := owner.(structuredLoggerProvider); ok {
+		if attached := provider.attachedLogger(); attached != nil {
+			logger = attached
+		}
+	}
+	if logger == nil {
+		return nil
+	}
+	if ctx == nil {
+		return logger
+	}
+	if fields, ok := ctx.Value(logFieldsKey{}).([]interface{}); ok && len(fields) > 0 {
+		return logger.WithValues(fields...)
+	}
+	return logger
+}
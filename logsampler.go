@@ -0,0 +1,180 @@
+// mgo - MongoDB driver for Go
+//
+// Copyright (c) 2010-2012 - Gustavo Niemeyer <gustavo@niemeyer.net>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package mgo
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler decides whether a repeated log line identified by key should
+// still be emitted. It lets a flapping replica set that would otherwise
+// spam gigabytes of identical "socket was closed" errors through errorln
+// get collapsed to a manageable rate instead of drowning Sentry.
+type Sampler interface {
+	// Allow reports whether the line identified by key should be
+	// emitted now.
+	Allow(key string) bool
+}
+
+// NewSampler returns a Sampler using a "first N then every Mth" strategy
+// per distinct key, reset once per window: the first `first` occurrences of
+// a given key in a window are always allowed, and thereafter only every
+// `thereafter`th occurrence is. A thereafter of 1000 with first=100 means a
+// key that fires constantly settles at roughly 100 + 1-in-1000 lines per
+// window instead of flooding the sink.
+func NewSampler(first, thereafter int, window time.Duration) Sampler {
+	return &tokenBucketSampler{
+		first:      first,
+		thereafter: thereafter,
+		window:     window,
+		counters:   map[string]*sampleCounter{},
+	}
+}
+
+type sampleCounter struct {
+	windowStart time.Time
+	count       int64
+	dropped     int64
+}
+
+type tokenBucketSampler struct {
+	mu         sync.Mutex
+	first      int
+	thereafter int
+	window     time.Duration
+	counters   map[string]*sampleCounter
+}
+
+func (s *tokenBucketSampler) Allow(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	counter, ok := s.counters[key]
+	if !ok || now.Sub(counter.windowStart) >= s.window {
+		counter = &sampleCounter{windowStart: now}
+		s.counters[key] = counter
+	}
+	counter.count++
+
+	if counter.count <= int64(s.first) {
+		return true
+	}
+	if s.thereafter <= 0 {
+		counter.dropped++
+		return false
+	}
+	if (counter.count-int64(s.first))%int64(s.thereafter) == 0 {
+		return true
+	}
+	counter.dropped++
+	return false
+}
+
+var (
+	samplerMu     sync.Mutex
+	globalSampler Sampler = NewSampler(100, 1000, time.Second)
+)
+
+// SetLogSampler replaces the sampler used to throttle repeated log lines.
+// Pass nil to disable sampling and emit every line.
+func SetLogSampler(sampler Sampler) {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+	globalSampler = sampler
+}
+
+func getLogSampler() Sampler {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+	return globalSampler
+}
+
+var droppedLogCount int64
+
+func init() {
+	go reportDroppedLogs()
+}
+
+// reportDroppedLogs flushes a count of sampled-away log lines once per
+// second, mirroring zap's sampler core so operators can see how much was
+// collapsed without it drowning out the lines that did get through.
+func reportDroppedLogs() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		dropped := atomic.SwapInt64(&droppedLogCount, 0)
+		if dropped > 0 {
+			// skip=0: this ticker goroutine is the call site itself, there's
+			// no further external wrapper above it to attribute to.
+			writeLogFile(LOG_WARN, getLogger(""), fmt.Sprintf("log sampler dropped %d repeated entries in the last second", dropped), 0)
+		}
+	}
+}
+
+// sampledOut reports whether the log line currently being written should be
+// dropped by the configured sampler. The dedup key is the call site
+// (file:line) that ultimately asked to log, not the rendered message: a
+// flapping replica set calling errorln("socket was closed", addr, err)
+// renders a different string on every call (remote address, latency, error
+// text all vary), so keying on that text would never collapse anything.
+// Keying on the call site is what actually throttles a hot errorln/debugf.
+//
+// skip is threaded in from writeLogFile's own skip argument rather than
+// hardcoded: writeLogFile is reached through varying amounts of
+// indirection (log()/debug() call it directly, logfmtAdapter.Log is one
+// layer further removed via logKV), so a single fixed frame count would
+// collapse every caller reached through the deeper path onto one key.
+func sampledOut(skip int) bool {
+	sampler := getLogSampler()
+	if sampler == nil {
+		return false
+	}
+	if sampler.Allow(sampleCallSite(skip)) {
+		return false
+	}
+	atomic.AddInt64(&droppedLogCount, 1)
+	return true
+}
+
+// sampleCallSite identifies the call site skip frames above writeLogFile's
+// caller, using the same convention as effectiveLogLevel: skip=1 reaches
+// the code that called a thin wrapper like log()/debug() directly; larger
+// values account for additional indirection such as logfmtAdapter.Log
+// being invoked through logKV. The constant 3 accounts for the three
+// frames always on the stack between here and writeLogFile's caller:
+// sampleCallSite itself, sampledOut, and writeLogFile.
+func sampleCallSite(skip int) string {
+	if _, file, line, ok := runtime.Caller(skip + 3); ok {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return "unknown"
+}
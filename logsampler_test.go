@@ -0,0 +1,120 @@
+package mgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplerAllowsFirstNThenEveryMth(t *testing.T) {
+	sampler := NewSampler(3, 5, time.Minute)
+
+	var allowed int
+	for i := 0; i < 3+5*3; i++ {
+		if sampler.Allow("socket was closed") {
+			allowed++
+		}
+	}
+
+	// first=3 always allowed, then 1-in-5 for the next 15 calls -> 3 more.
+	want := 3 + 3
+	if allowed != want {
+		t.Fatalf("allowed = %d, want %d", allowed, want)
+	}
+}
+
+func TestSamplerKeysAreIndependent(t *testing.T) {
+	sampler := NewSampler(1, 1000, time.Minute)
+
+	if !sampler.Allow("key-a") {
+		t.Fatal("first occurrence of key-a should be allowed")
+	}
+	if sampler.Allow("key-a") {
+		t.Fatal("second occurrence of key-a should be sampled out (before the Mth)")
+	}
+	if !sampler.Allow("key-b") {
+		t.Fatal("first occurrence of a distinct key-b should be allowed regardless of key-a's state")
+	}
+}
+
+func TestSamplerResetsCounterAfterWindow(t *testing.T) {
+	sampler := NewSampler(1, 1000, 10*time.Millisecond)
+
+	if !sampler.Allow("flapping") {
+		t.Fatal("first occurrence should be allowed")
+	}
+	if sampler.Allow("flapping") {
+		t.Fatal("second occurrence within the window should be sampled out")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !sampler.Allow("flapping") {
+		t.Fatal("first occurrence of a new window should be allowed again")
+	}
+}
+
+func TestSamplerThereafterZeroDropsEverything(t *testing.T) {
+	sampler := NewSampler(1, 0, time.Minute)
+
+	if !sampler.Allow("k") {
+		t.Fatal("first occurrence should be allowed")
+	}
+	if sampler.Allow("k") {
+		t.Fatal("with thereafter=0, every occurrence past first should be dropped")
+	}
+}
+
+// recordingSampler captures the keys it's asked about instead of actually
+// sampling, so tests can assert on what writeLogFile resolved the call
+// site to.
+type recordingSampler struct {
+	keys []string
+}
+
+func (r *recordingSampler) Allow(key string) bool {
+	r.keys = append(r.keys, key)
+	return true
+}
+
+// logLikeWrapper mirrors log()/debug(): a single thin wrapper directly
+// around writeLogFile, using skip=1.
+func logLikeWrapper(msg string) {
+	writeLogFile(LOG_INFO, nil, msg, 1)
+}
+
+func TestSampledOutKeysDistinctCallSitesThroughSingleWrapper(t *testing.T) {
+	rec := &recordingSampler{}
+	prev := getLogSampler()
+	SetLogSampler(rec)
+	defer SetLogSampler(prev)
+
+	logLikeWrapper("a") // distinct source line from the one below
+	logLikeWrapper("a")
+
+	if len(rec.keys) != 2 || rec.keys[0] == rec.keys[1] {
+		t.Fatalf("expected distinct call-site keys for two distinct call lines through a single wrapper, got %v", rec.keys)
+	}
+}
+
+// adapterOuterA/adapterOuterB mirror two distinct callers reaching
+// logfmtAdapter.Log through logKV's extra frame of indirection; both go
+// through the same adapterKV (standing in for logKV) and adapterInner
+// (standing in for logfmtAdapter.Log), using skip=2.
+func adapterOuterA(msg string) { adapterKV(msg) }
+func adapterOuterB(msg string) { adapterKV(msg) }
+func adapterKV(msg string)     { adapterInner(msg) }
+func adapterInner(msg string)  { writeLogFile(LOG_INFO, nil, msg, 2) }
+
+func TestSampledOutAccountsForExtraIndirectionLikeLogfmtAdapter(t *testing.T) {
+	rec := &recordingSampler{}
+	prev := getLogSampler()
+	SetLogSampler(rec)
+	defer SetLogSampler(prev)
+
+	adapterOuterA("a")
+	adapterOuterB("a")
+
+	if len(rec.keys) != 2 || rec.keys[0] == rec.keys[1] {
+		t.Fatalf("expected skip=2 to resolve to each distinct outer caller (adapterOuterA vs adapterOuterB) instead of collapsing onto adapterKV's one fixed line, got %v", rec.keys)
+	}
+}
@@ -0,0 +1,274 @@
+// mgo - MongoDB driver for Go
+//
+// Copyright (c) 2010-2012 - Gustavo Niemeyer <gustavo@niemeyer.net>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package mgo
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// LogSink receives log lines in addition to the single globalLogger that
+// SetLogger/SetLoggerFunc configure. Callers register one or more sinks
+// (stderr, file, syslog, journald, a network collector, ...) with
+// RegisterLogSink; every line written through writeLogFile is fanned out to
+// all registered sinks on a background goroutine so a slow sink never blocks
+// a query goroutine.
+type LogSink interface {
+	// Write delivers a single log line at the given level (one of the
+	// LOG_* constants). line is the raw message passed to log/debug/
+	// errorln and friends, independent of whatever prefix/timestamp
+	// SetLoggerFunc's formatLogf would add -- a sink is expected to
+	// apply its own formatting, since it may be registered without any
+	// legacy logLogger configured at all.
+	Write(level int, line string)
+
+	// Flush blocks until any buffered data the sink holds has been
+	// written out, for use during graceful shutdown.
+	Flush() error
+}
+
+const sinkQueueSize = 1024
+
+var (
+	sinkMu      sync.Mutex
+	logSinks    []LogSink
+	sinkPumpped sync.Once
+
+	// sinkCh is read by publishToSinks on every log line and written once
+	// by RegisterLogSink's sinkPumpped.Do -- a bare package var there would
+	// race under go test -race the same way every other mutable global in
+	// this package would if it weren't guarded by globalMutex, so it's an
+	// atomic.Pointer instead rather than a pointer protected only by the
+	// Once (which serializes writers against each other but not against
+	// concurrent readers).
+	sinkCh atomic.Pointer[chan sinkEntry]
+)
+
+type sinkEntry struct {
+	level int
+	line  string
+}
+
+// RegisterLogSink adds sink to the set of sinks that receive every log line
+// written through writeLogFile, and starts the background flusher the first
+// time it's called.
+func RegisterLogSink(sink LogSink) {
+	sinkMu.Lock()
+	logSinks = append(logSinks, sink)
+	sinkMu.Unlock()
+
+	sinkPumpped.Do(func() {
+		ch := make(chan sinkEntry, sinkQueueSize)
+		sinkCh.Store(&ch)
+		go pumpLogSinks(ch)
+	})
+}
+
+// pumpLogSinks is the background flusher: it owns the only receive end of
+// ch, so fanning a line out to every registered sink never happens on the
+// caller's goroutine.
+func pumpLogSinks(ch chan sinkEntry) {
+	for entry := range ch {
+		sinkMu.Lock()
+		sinks := append([]LogSink(nil), logSinks...)
+		sinkMu.Unlock()
+
+		for _, sink := range sinks {
+			sink.Write(entry.level, entry.line)
+		}
+	}
+}
+
+// publishToSinks enqueues line for delivery to the registered sinks without
+// blocking the caller. If the queue is full the line is dropped, since a
+// flapping replica set producing log lines faster than sinks can drain them
+// must not be allowed to stall query goroutines.
+func publishToSinks(level int, line string) {
+	ch := sinkCh.Load()
+	if ch == nil {
+		return
+	}
+	select {
+	case *ch <- sinkEntry{level, line}:
+	default:
+	}
+}
+
+// FlushLogSinks blocks until every registered LogSink has flushed any
+// buffered data, for use during graceful shutdown.
+func FlushLogSinks() {
+	sinkMu.Lock()
+	sinks := append([]LogSink(nil), logSinks...)
+	sinkMu.Unlock()
+
+	for _, sink := range sinks {
+		sink.Flush()
+	}
+}
+
+// FileSink is a LogSink that appends to a file, rotating it once it grows
+// past maxBytes or gets older than maxAge (either may be zero to disable
+// that trigger).
+type FileSink struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+	file     *os.File
+	written  int64
+	openedAt time.Time
+}
+
+// NewFileSink opens (creating if necessary) the file at path and returns a
+// FileSink that rotates it according to maxBytes and maxAge.
+func NewFileSink(path string, maxBytes int64, maxAge time.Duration) (*FileSink, error) {
+	sink := &FileSink{path: path, maxBytes: maxBytes, maxAge: maxAge}
+	if err := sink.openLocked(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (f *FileSink) openLocked() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.written = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+// Write implements LogSink, rotating the file first if it has grown past
+// maxBytes or aged past maxAge.
+func (f *FileSink) Write(level int, line string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return
+	}
+	if (f.maxBytes > 0 && f.written >= f.maxBytes) ||
+		(f.maxAge > 0 && time.Since(f.openedAt) >= f.maxAge) {
+		if err := f.rotateLocked(); err != nil {
+			// f.file is nil after a failed rotate, so the next Write call
+			// returns at the nil check above instead of retrying the same
+			// failing rotate on every line -- but an operator needs to see
+			// this, since it otherwise means the sink just went dark.
+			errorln(err)
+			return
+		}
+	}
+
+	n, _ := fmt.Fprintln(f.file, line)
+	f.written += int64(n)
+}
+
+// rotateLocked closes the current file, renames it aside, and opens a
+// fresh one in its place. On any failure it leaves f.file nil (rather than
+// pointing at the now-closed descriptor) so Write stops retrying the same
+// broken rotation on every call, and returns the error so the caller can
+// surface it instead of losing all subsequent output silently.
+func (f *FileSink) rotateLocked() error {
+	if err := f.file.Close(); err != nil {
+		f.file = nil
+		return fmt.Errorf("mgo: FileSink: closing %q before rotation: %w", f.path, err)
+	}
+
+	rotated := f.path + "." + time.Now().Format("20060102T150405")
+	if err := os.Rename(f.path, rotated); err != nil {
+		f.file = nil
+		return fmt.Errorf("mgo: FileSink: renaming %q to %q: %w", f.path, rotated, err)
+	}
+
+	if err := f.openLocked(); err != nil {
+		f.file = nil
+		return fmt.Errorf("mgo: FileSink: reopening %q after rotation: %w", f.path, err)
+	}
+	return nil
+}
+
+// Reopen closes and reopens the underlying file, picking up a rename done
+// out-of-band by logrotate without losing any lines and without requiring
+// the process to restart. WatchSIGHUP wires this to SIGHUP automatically.
+func (f *FileSink) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	return f.openLocked()
+}
+
+// Flush implements LogSink.
+func (f *FileSink) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close closes the underlying file. The FileSink must not be written to
+// after Close returns.
+func (f *FileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// WatchSIGHUP reopens sink whenever the process receives SIGHUP, so logs
+// can be rotated by logrotate (or similar) without restarting the driver.
+func WatchSIGHUP(sink *FileSink) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			sink.Reopen()
+		}
+	}()
+}
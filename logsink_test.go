@@ -0,0 +1,95 @@
+package mgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSinkRotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mgo.log")
+
+	sink, err := NewFileSink(path, 10, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	sink.Write(LOG_INFO, "0123456789") // 10 bytes plus newline reaches maxBytes, triggers rotation next write
+	sink.Write(LOG_INFO, "next")
+	sink.Flush()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("expected a rotated file alongside %q, got %d entries: %v", path, len(entries), entries)
+	}
+}
+
+func TestFileSinkReopenPicksUpRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mgo.log")
+
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	sink.Write(LOG_INFO, "before rotate")
+	sink.Flush()
+
+	renamed := path + ".logrotate"
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if err := sink.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	sink.Write(LOG_INFO, "after rotate")
+	sink.Flush()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected Reopen to recreate %q: %v", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(data) == 0 {
+		t.Fatal("expected the reopened file to contain the post-rotate write")
+	}
+}
+
+func TestRegisterLogSinkDeliversWithoutLegacyLogger(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mgo.log")
+
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	defer sink.Close()
+
+	RegisterLogSink(sink)
+	writeLogFile(LOG_INFO, nil, "hello from a sink-only caller", 0)
+
+	deadline := time.Now().Add(time.Second)
+	var data []byte
+	for time.Now().Before(deadline) {
+		FlushLogSinks()
+		data, err = os.ReadFile(path)
+		if err == nil && len(data) > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(data) == 0 {
+		t.Fatal("expected the sink to receive the line even with globalFormatLogf unset")
+	}
+}
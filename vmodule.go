@@ -0,0 +1,173 @@
+// mgo - MongoDB driver for Go
+//
+// Copyright (c) 2010-2012 - Gustavo Niemeyer <gustavo@niemeyer.net>
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice, this
+//    list of conditions and the following disclaimer.
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+//    this list of conditions and the following disclaimer in the documentation
+//    and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+// ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+// WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+// DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+// ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+// (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+// ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+// SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package mgo
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Verbose is the result of a V(level) check. It exists so that guarding an
+// expensive debug call can be written as:
+//
+//	if mgo.V(3).Enabled() {
+//	    debugf("cluster members: %#v", members)
+//	}
+//
+// so the expensive argument construction is skipped entirely when the
+// verbosity gate is closed.
+type Verbose bool
+
+// Enabled reports whether this verbosity level is currently enabled. The
+// check behind V reads the vmodule rule table under vmoduleMu's read lock
+// and then does an integer comparison (plus one runtime.Caller lookup when
+// vmodule rules are configured). That read lock is taken on every V call,
+// race detector or not; it is not elided the way the raceDetector-guarded
+// globalMutex locks are.
+func (v Verbose) Enabled() bool {
+	return bool(v)
+}
+
+var globalVerbosity int
+
+// SetVerbosity sets the default V level used by V(level) for files with no
+// matching SetVModule rule. Higher values enable more verbose logging.
+func SetVerbosity(level int) {
+	if raceDetector {
+		globalMutex.Lock()
+		defer globalMutex.Unlock()
+	}
+	globalVerbosity = level
+}
+
+type vmoduleRule struct {
+	pattern string
+	level   int
+}
+
+var (
+	vmoduleMu    sync.RWMutex
+	vmoduleRules []vmoduleRule
+)
+
+// SetVModule overrides the default V level on a per-source-file basis using
+// a klog-style "pattern=level,pattern=level" spec, e.g.
+// "cluster=3,socket=2,server=1". Each pattern is matched, shell-glob style
+// via filepath.Match, against the base name of the source file (without the
+// ".go" suffix) that a V(level) call originates from; the first matching
+// rule wins and files with no match fall back to SetVerbosity. This lets
+// operators crank up V verbosity for cluster.go while keeping the rest of
+// mgo quiet.
+//
+// The same number also replaces globalLogLevel as the LOG_* threshold for
+// log/logln/logf, debug/debugln/debugf and logKV calls made from a matching
+// file (see effectiveLogLevel). There a lower value is more verbose: with
+// "cluster=3", V(3) is enabled in cluster.go while its log and debug calls
+// below LOG_ERROR are suppressed; "cluster=0" lets them all through.
+func SetVModule(spec string) error {
+	var rules []vmoduleRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("mgo: invalid vmodule entry %q", part)
+		}
+		level, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return fmt.Errorf("mgo: invalid vmodule level in %q: %v", part, err)
+		}
+		rules = append(rules, vmoduleRule{pattern: kv[0], level: level})
+	}
+
+	vmoduleMu.Lock()
+	vmoduleRules = rules
+	vmoduleMu.Unlock()
+	return nil
+}
+
+// vmoduleLevel returns the configured verbosity override for the given
+// source file path, if any vmodule rule matches it.
+func vmoduleLevel(file string) (level int, matched bool) {
+	vmoduleMu.RLock()
+	defer vmoduleMu.RUnlock()
+	if len(vmoduleRules) == 0 {
+		return 0, false
+	}
+	base := strings.TrimSuffix(filepath.Base(file), ".go")
+	for _, rule := range vmoduleRules {
+		if ok, _ := filepath.Match(rule.pattern, base); ok {
+			return rule.level, true
+		}
+	}
+	return 0, false
+}
+
+// V reports whether verbosity level `level` is enabled for the calling
+// file, consulting SetVModule overrides before falling back to the default
+// set with SetVerbosity.
+func V(level int) Verbose {
+	if len(currentVModuleRules()) > 0 {
+		if _, file, _, ok := runtime.Caller(1); ok {
+			if vlevel, ok := vmoduleLevel(file); ok {
+				return Verbose(level <= vlevel)
+			}
+		}
+	}
+	return Verbose(level <= globalVerbosity)
+}
+
+func currentVModuleRules() []vmoduleRule {
+	vmoduleMu.RLock()
+	defer vmoduleMu.RUnlock()
+	return vmoduleRules
+}
+
+// effectiveLogLevel returns the LOG_* threshold that should gate a call
+// `skip` frames above its own caller (i.e. pass 1 from a function that
+// directly wraps writeLogFile, the same convention as runtime.Caller),
+// consulting SetVModule for that call site's source file before falling
+// back to globalLogLevel. This is what makes SetVModule actually override
+// the log/logln/logf/debug/debugln/debugf gates in log.go instead of only
+// affecting the separate V(level) gate.
+func effectiveLogLevel(skip int) int {
+	if len(currentVModuleRules()) == 0 {
+		return globalLogLevel
+	}
+	if _, file, _, ok := runtime.Caller(skip + 1); ok {
+		if level, matched := vmoduleLevel(file); matched {
+			return level
+		}
+	}
+	return globalLogLevel
+}
@@ -0,0 +1,70 @@
+package mgo
+
+import "testing"
+
+func TestSetVModuleMatchesBaseName(t *testing.T) {
+	if err := SetVModule("cluster=3,socket=2"); err != nil {
+		t.Fatalf("SetVModule returned error: %v", err)
+	}
+	defer SetVModule("")
+
+	level, matched := vmoduleLevel("/go/src/github.com/viphxin/mgo/cluster.go")
+	if !matched || level != 3 {
+		t.Fatalf("vmoduleLevel(cluster.go) = (%d, %v), want (3, true)", level, matched)
+	}
+
+	level, matched = vmoduleLevel("/go/src/github.com/viphxin/mgo/socket.go")
+	if !matched || level != 2 {
+		t.Fatalf("vmoduleLevel(socket.go) = (%d, %v), want (2, true)", level, matched)
+	}
+
+	if _, matched := vmoduleLevel("/go/src/github.com/viphxin/mgo/log.go"); matched {
+		t.Fatalf("vmoduleLevel(log.go) matched, want no match for a file with no rule")
+	}
+}
+
+func TestSetVModuleRejectsInvalidSpec(t *testing.T) {
+	if err := SetVModule("cluster=notanumber"); err == nil {
+		t.Fatal("SetVModule accepted a non-numeric level, want error")
+	}
+	if err := SetVModule("noequalssign"); err == nil {
+		t.Fatal("SetVModule accepted an entry with no '=', want error")
+	}
+}
+
+func TestEffectiveLogLevelUsesVModuleOverride(t *testing.T) {
+	globalLogLevel = LOG_ERROR
+	defer func() { globalLogLevel = LOG_INFO }()
+
+	if err := SetVModule("vmodule_test=" + "0"); err != nil {
+		t.Fatalf("SetVModule returned error: %v", err)
+	}
+	defer SetVModule("")
+
+	if level := effectiveLogLevel(0); level != LOG_DEBUG {
+		t.Fatalf("effectiveLogLevel = %d, want %d (vmodule override for this file)", level, LOG_DEBUG)
+	}
+}
+
+func TestEffectiveLogLevelFallsBackWithoutVModule(t *testing.T) {
+	SetVModule("")
+	globalLogLevel = LOG_WARN
+	defer func() { globalLogLevel = LOG_INFO }()
+
+	if level := effectiveLogLevel(0); level != LOG_WARN {
+		t.Fatalf("effectiveLogLevel = %d, want globalLogLevel %d", level, LOG_WARN)
+	}
+}
+
+func TestVReflectsVerbosity(t *testing.T) {
+	SetVModule("")
+	SetVerbosity(2)
+	defer SetVerbosity(0)
+
+	if !V(2).Enabled() {
+		t.Fatal("V(2).Enabled() = false, want true at verbosity 2")
+	}
+	if V(3).Enabled() {
+		t.Fatal("V(3).Enabled() = true, want false at verbosity 2")
+	}
+}